@@ -0,0 +1,70 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GroupName and GroupVersion identify the API group and version the
+// kubevirt.io types are served under.
+const (
+	GroupName    = "kubevirt.io"
+	GroupVersion = "v1alpha1"
+)
+
+// VirtualMachine is a mirror of the VirtualMachine domain object kept by
+// virt-controller. It is the unit callers interact with through kubecli.
+type VirtualMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachineSpec   `json:"spec,omitempty"`
+	Status VirtualMachineStatus `json:"status,omitempty"`
+}
+
+// VirtualMachineSpec holds the desired state of a VirtualMachine.
+type VirtualMachineSpec struct {
+	// Running controls whether the associated domain is currently running.
+	Running bool `json:"running"`
+}
+
+// VirtualMachineStatus holds the observed state of a VirtualMachine.
+type VirtualMachineStatus struct {
+	// NodeName is the name of the node the domain is currently scheduled on.
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// VirtualMachineList is a list of VirtualMachines.
+type VirtualMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []VirtualMachine `json:"items"`
+}
+
+// NewMinimalVM returns a VirtualMachine with just a name set, useful for tests
+// that only care about round-tripping an object through the client.
+func NewMinimalVM(name string) *VirtualMachine {
+	return &VirtualMachine{
+		TypeMeta:   metav1.TypeMeta{APIVersion: GroupName + "/" + GroupVersion, Kind: "VirtualMachine"},
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+	}
+}
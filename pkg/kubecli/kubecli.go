@@ -0,0 +1,119 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+// Package kubecli contains a thin client for talking to the kubevirt.io
+// apiserver extension, on top of a regular Kubernetes REST client.
+package kubecli
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+// Scheme is the runtime.Scheme kubecli decodes API responses against. It
+// carries both the regular Kubernetes types and kubevirt.io's own, since a
+// VirtualMachine is served alongside core resources by the same apiserver.
+var Scheme = runtime.NewScheme()
+
+// Codecs are the serializers kubecli's REST client negotiates with virt-api
+// over, built from Scheme.
+var Codecs = serializer.NewCodecFactory(Scheme)
+
+// ParameterCodec turns metav1.[Get|List|Delete]Options into URL query
+// parameters. It has to be built from Scheme rather than reused from
+// client-go's own scheme package, since that one never saw kubevirt.io/
+// v1alpha1 and can't encode options for it.
+var ParameterCodec = runtime.NewParameterCodec(Scheme)
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(Scheme))
+	utilruntime.Must(v1.AddToScheme(Scheme))
+}
+
+// KubevirtClient is the entrypoint for all kubevirt.io subresources, grouped
+// by the namespaced object they operate on.
+type KubevirtClient interface {
+	VM(namespace string) VMInterface
+	RestClient() *rest.RESTClient
+	Config() *rest.Config
+}
+
+type kubevirt struct {
+	master     string
+	kubeconfig string
+	config     *rest.Config
+	restClient *rest.RESTClient
+}
+
+func (k kubevirt) VM(namespace string) VMInterface {
+	return &vm{
+		restClient: k.restClient,
+		config:     k.config,
+		namespace:  namespace,
+		resource:   "virtualmachines",
+	}
+}
+
+func (k kubevirt) RestClient() *rest.RESTClient {
+	return k.restClient
+}
+
+func (k kubevirt) Config() *rest.Config {
+	return k.config
+}
+
+// GetKubevirtClientFromFlags builds a KubevirtClient from a master URL and an
+// optional kubeconfig path, mirroring how kubectl-style tools bootstrap their
+// clients from CLI flags.
+func GetKubevirtClientFromFlags(master string, kubeconfig string) (KubevirtClient, error) {
+	config, err := clientcmd.BuildConfigFromFlags(master, kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	return GetKubevirtClientFromRESTConfig(config)
+}
+
+// GetKubevirtClientFromRESTConfig builds a KubevirtClient on top of an
+// already assembled rest.Config, for callers embedding kubevirt clients in
+// their own binaries.
+func GetKubevirtClientFromRESTConfig(config *rest.Config) (KubevirtClient, error) {
+	shallowCopy := *config
+	shallowCopy.GroupVersion = &schema.GroupVersion{Group: v1.GroupName, Version: v1.GroupVersion}
+	shallowCopy.APIPath = "/apis"
+	shallowCopy.NegotiatedSerializer = serializer.WithoutConversionCodecFactory{CodecFactory: Codecs}
+	shallowCopy.ContentType = "application/json"
+
+	restClient, err := rest.RESTClientFor(&shallowCopy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kubevirt{
+		master:     config.Host,
+		config:     &shallowCopy,
+		restClient: restClient,
+	}, nil
+}
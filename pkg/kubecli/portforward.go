@@ -0,0 +1,252 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/rest"
+)
+
+// PortForwarder keeps a set of local TCP listeners forwarding traffic to a
+// VM's ports over a single subresource websocket, analogous to Helm's
+// kube/portforwarder and kubectl's `port-forward`.
+type PortForwarder interface {
+	// ForwardPorts blocks, accepting local connections and forwarding them,
+	// until Close is called or forwarding fails irrecoverably.
+	ForwardPorts() error
+	// Close stops forwarding and releases all local listeners.
+	Close()
+}
+
+// forwardedPort is one "local:remote" pair as accepted by kubectl-style
+// port-forward specs; a bare "N" means local and remote are the same.
+type forwardedPort struct {
+	local, remote uint16
+}
+
+// frameHeader is prepended to every websocket message multiplexed over the
+// portforward connection, so a single upgraded connection can carry many
+// concurrent local TCP connections across many remote ports.
+type frameHeader struct {
+	port     uint16
+	streamID uint16
+}
+
+const frameHeaderSize = 4
+
+func (f frameHeader) marshal() []byte {
+	b := make([]byte, frameHeaderSize)
+	binary.BigEndian.PutUint16(b[0:2], f.port)
+	binary.BigEndian.PutUint16(b[2:4], f.streamID)
+	return b
+}
+
+func unmarshalFrameHeader(b []byte) frameHeader {
+	return frameHeader{
+		port:     binary.BigEndian.Uint16(b[0:2]),
+		streamID: binary.BigEndian.Uint16(b[2:4]),
+	}
+}
+
+type portForwarder struct {
+	config    *rest.Config
+	namespace string
+	resource  string
+	name      string
+	ports     []forwardedPort
+
+	mu        sync.Mutex
+	conn      *websocket.Conn
+	listeners []net.Listener
+	streams   map[uint16]net.Conn
+	nextID    uint16
+	stopCh    chan struct{}
+}
+
+// PortForward returns a PortForwarder for the named VM that, once
+// ForwardPorts is called, dials the VM's portforward subresource, listens on
+// local TCP sockets for each of ports (in "local:remote" or "port" kubectl
+// notation) and pipes traffic to the matching guest port over the shared
+// websocket. PortForward itself only parses ports and opens no connection.
+func (v *vm) PortForward(name string, ports ...string) (PortForwarder, error) {
+	parsed, err := parseForwardedPorts(ports)
+	if err != nil {
+		return nil, err
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("at least one port must be specified")
+	}
+
+	return &portForwarder{
+		config:    v.config,
+		namespace: v.namespace,
+		resource:  v.resource,
+		name:      name,
+		ports:     parsed,
+		streams:   map[uint16]net.Conn{},
+		stopCh:    make(chan struct{}),
+	}, nil
+}
+
+func parseForwardedPorts(ports []string) ([]forwardedPort, error) {
+	parsed := make([]forwardedPort, 0, len(ports))
+	for _, p := range ports {
+		var localStr, remoteStr string
+		if strings.Contains(p, ":") {
+			parts := strings.SplitN(p, ":", 2)
+			localStr, remoteStr = parts[0], parts[1]
+		} else {
+			localStr, remoteStr = p, p
+		}
+
+		local, err := strconv.ParseUint(localStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwarded port %q: %v", p, err)
+		}
+		remote, err := strconv.ParseUint(remoteStr, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwarded port %q: %v", p, err)
+		}
+		parsed = append(parsed, forwardedPort{local: uint16(local), remote: uint16(remote)})
+	}
+	return parsed, nil
+}
+
+func (pf *portForwarder) ForwardPorts() error {
+	conn, err := dialSubresource(pf.config, pf.namespace, pf.resource, pf.name, "portforward")
+	if err != nil {
+		return err
+	}
+	pf.mu.Lock()
+	pf.conn = conn
+	pf.mu.Unlock()
+
+	go pf.demux()
+
+	for _, fp := range pf.ports {
+		listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", fp.local))
+		if err != nil {
+			pf.Close()
+			return err
+		}
+		pf.mu.Lock()
+		pf.listeners = append(pf.listeners, listener)
+		pf.mu.Unlock()
+
+		go pf.acceptLoop(listener, fp.remote)
+	}
+
+	<-pf.stopCh
+	return nil
+}
+
+func (pf *portForwarder) acceptLoop(listener net.Listener, remotePort uint16) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		pf.mu.Lock()
+		streamID := pf.nextID
+		pf.nextID++
+		pf.streams[streamID] = conn
+		pf.mu.Unlock()
+
+		go pf.copyToRemote(conn, frameHeader{port: remotePort, streamID: streamID})
+	}
+}
+
+func (pf *portForwarder) copyToRemote(local net.Conn, header frameHeader) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := local.Read(buf)
+		if n > 0 {
+			msg := append(header.marshal(), buf[:n]...)
+			pf.mu.Lock()
+			writeErr := pf.conn.WriteMessage(websocket.BinaryMessage, msg)
+			pf.mu.Unlock()
+			if writeErr != nil {
+				break
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	pf.mu.Lock()
+	delete(pf.streams, header.streamID)
+	pf.mu.Unlock()
+	local.Close()
+}
+
+func (pf *portForwarder) demux() {
+	for {
+		_, message, err := pf.conn.ReadMessage()
+		if err != nil {
+			pf.Close()
+			return
+		}
+		if len(message) < frameHeaderSize {
+			continue
+		}
+		header := unmarshalFrameHeader(message[:frameHeaderSize])
+		payload := message[frameHeaderSize:]
+
+		pf.mu.Lock()
+		local, ok := pf.streams[header.streamID]
+		pf.mu.Unlock()
+		if !ok {
+			continue
+		}
+		if _, err := local.Write(payload); err != nil && err != io.EOF {
+			local.Close()
+		}
+	}
+}
+
+func (pf *portForwarder) Close() {
+	pf.mu.Lock()
+	defer pf.mu.Unlock()
+
+	select {
+	case <-pf.stopCh:
+		return
+	default:
+		close(pf.stopCh)
+	}
+
+	for _, l := range pf.listeners {
+		l.Close()
+	}
+	for _, c := range pf.streams {
+		c.Close()
+	}
+	if pf.conn != nil {
+		pf.conn.Close()
+	}
+}
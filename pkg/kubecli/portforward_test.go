@@ -0,0 +1,117 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Kubevirt VM PortForward", func() {
+
+	var upgrader websocket.Upgrader
+	var server *ghttp.Server
+	var client KubevirtClient
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		client, err = GetKubevirtClientFromFlags(server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	freePort := func() int {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).ToNot(HaveOccurred())
+		defer l.Close()
+		return l.Addr().(*net.TCPAddr).Port
+	}
+
+	It("should round-trip data through a forwarded port", func() {
+		portForwardPath := "/apis/subresources.kubevirt.io/v1alpha1/namespaces/default/virtualmachines/testvm/portforward"
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", portForwardPath),
+			func(w http.ResponseWriter, r *http.Request) {
+				c, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					panic("server upgrader failed")
+				}
+				defer c.Close()
+
+				for {
+					mt, message, err := c.ReadMessage()
+					if err != nil {
+						break
+					}
+					if err := c.WriteMessage(mt, message); err != nil {
+						break
+					}
+				}
+			},
+		))
+
+		localPort := freePort()
+
+		pf, err := client.VM(k8sv1.NamespaceDefault).PortForward("testvm", fmt.Sprintf("%d:8080", localPort))
+		Expect(err).ToNot(HaveOccurred())
+
+		go func() {
+			defer GinkgoRecover()
+			pf.ForwardPorts()
+		}()
+		defer pf.Close()
+
+		Eventually(func() error {
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+			if err != nil {
+				return err
+			}
+			conn.Close()
+			return nil
+		}, time.Second, 10*time.Millisecond).Should(Succeed())
+
+		conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", localPort))
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+
+		msg := "hello, portforward!"
+		_, err = conn.Write([]byte(msg))
+		Expect(err).ToNot(HaveOccurred())
+
+		buf := make([]byte, len(msg))
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		_, err = conn.Read(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(string(buf)).To(Equal(msg))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+})
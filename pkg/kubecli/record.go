@@ -0,0 +1,275 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// recordingMagic identifies a kubecli stream recording; readers should
+// reject anything that doesn't start with it.
+const recordingMagic = "KVREC1"
+
+const recordingVersion = 1
+
+// StreamKind distinguishes what kind of subresource stream was recorded. It
+// is stored in the recording header; Replay and ConvertToAsciicast do not
+// look at it.
+type StreamKind uint8
+
+const (
+	StreamKindVNC StreamKind = iota
+	StreamKindSerialConsole
+)
+
+// Direction tags which side of the stream a recorded frame travelled.
+type Direction uint8
+
+const (
+	DirectionClientToServer Direction = iota
+	DirectionServerToClient
+)
+
+// RecordingHeader is the self-describing preamble written once at the start
+// of a recording, before any frames.
+type RecordingHeader struct {
+	StreamKind StreamKind
+	Timestamp  time.Time
+	// Width and Height are a hint at the screen geometry the recording was
+	// taken against; 0 if not applicable (e.g. a plain byte stream).
+	Width, Height uint16
+}
+
+func (h RecordingHeader) write(w io.Writer) error {
+	if _, err := io.WriteString(w, recordingMagic); err != nil {
+		return err
+	}
+	buf := make([]byte, 1+8+2+2)
+	buf[0] = byte(h.StreamKind)
+	binary.BigEndian.PutUint64(buf[1:9], uint64(h.Timestamp.UnixNano()))
+	binary.BigEndian.PutUint16(buf[9:11], h.Width)
+	binary.BigEndian.PutUint16(buf[11:13], h.Height)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readRecordingHeader(r io.Reader) (RecordingHeader, error) {
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return RecordingHeader{}, err
+	}
+	if string(magic) != recordingMagic {
+		return RecordingHeader{}, fmt.Errorf("not a kubecli stream recording")
+	}
+
+	buf := make([]byte, 1+8+2+2)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return RecordingHeader{}, err
+	}
+
+	return RecordingHeader{
+		StreamKind: StreamKind(buf[0]),
+		Timestamp:  time.Unix(0, int64(binary.BigEndian.Uint64(buf[1:9]))),
+		Width:      binary.BigEndian.Uint16(buf[9:11]),
+		Height:     binary.BigEndian.Uint16(buf[11:13]),
+	}, nil
+}
+
+// recordedFrame is one length-prefixed, direction-tagged, timestamped chunk
+// of traffic.
+type recordedFrame struct {
+	direction   Direction
+	deltaMicros uint64
+	payload     []byte
+}
+
+func (f recordedFrame) write(w io.Writer) error {
+	header := make([]byte, 1+8+4)
+	header[0] = byte(f.direction)
+	binary.BigEndian.PutUint64(header[1:9], f.deltaMicros)
+	binary.BigEndian.PutUint32(header[9:13], uint32(len(f.payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(f.payload)
+	return err
+}
+
+func readRecordedFrame(r io.Reader) (recordedFrame, error) {
+	header := make([]byte, 1+8+4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return recordedFrame{}, err
+	}
+
+	length := binary.BigEndian.Uint32(header[9:13])
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return recordedFrame{}, err
+	}
+
+	return recordedFrame{
+		direction:   Direction(header[0]),
+		deltaMicros: binary.BigEndian.Uint64(header[1:9]),
+		payload:     payload,
+	}, nil
+}
+
+// Recorder persists both directions of a StreamInterface's traffic to w in
+// the kubecli recording container format, so it can later be fed to Replay
+// or converted to asciicast.
+type Recorder struct {
+	mu    sync.Mutex
+	w     io.Writer
+	start time.Time
+}
+
+// NewRecorder writes the recording header to w and returns a Recorder ready
+// to have frames appended to it via Record.
+func NewRecorder(w io.Writer, header RecordingHeader) (*Recorder, error) {
+	if header.Timestamp.IsZero() {
+		header.Timestamp = time.Now()
+	}
+	if err := header.write(w); err != nil {
+		return nil, err
+	}
+	// start is the baseline frame deltas are measured against. It must be
+	// "now", not header.Timestamp: the header field is just a self-describing
+	// metadata hint and a caller is free to set it to anything, which would
+	// otherwise corrupt every recorded delta.
+	return &Recorder{w: w, start: time.Now()}, nil
+}
+
+// Record appends a single frame, stamped with the time elapsed since the
+// recording started.
+func (r *Recorder) Record(direction Direction, payload []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	frame := recordedFrame{
+		direction:   direction,
+		deltaMicros: uint64(time.Since(r.start).Microseconds()),
+		payload:     payload,
+	}
+	return frame.write(r.w)
+}
+
+// ReplayOptions tunes how Replay reproduces a recording.
+type ReplayOptions struct {
+	// Speed scales the delay between frames; 2 plays back twice as fast,
+	// 0.5 half as fast. Zero (the default value) means 1, i.e. original
+	// timing.
+	Speed float64
+}
+
+// Replay reads a recording from r and writes the server->client bytes to
+// out, sleeping between frames to reproduce their original timing (scaled
+// by opts.Speed). Client->server frames are skipped; they only ever existed
+// to drive the recorded session, not to be replayed at it.
+func Replay(r io.Reader, out io.Writer, opts ReplayOptions) error {
+	speed := opts.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	if _, err := readRecordingHeader(r); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	var lastDelta uint64
+	for {
+		frame, err := readRecordedFrame(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if frame.direction != DirectionServerToClient {
+			continue
+		}
+
+		wait := time.Duration(frame.deltaMicros-lastDelta) * time.Microsecond
+		lastDelta = frame.deltaMicros
+		if wait > 0 {
+			time.Sleep(time.Duration(float64(wait) / speed))
+		}
+
+		if _, err := out.Write(frame.payload); err != nil {
+			return err
+		}
+	}
+}
+
+// asciicastHeader is the first line of an asciicast v2 recording.
+type asciicastHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Command   string `json:"command,omitempty"`
+}
+
+// ConvertToAsciicast reads a kubecli serial-console recording from r and
+// writes it out as an asciicast v2 JSON stream, suitable for asciinema or
+// any other asciicast player. Only server->client ("output") frames are
+// represented, which is all asciicast v2 needs to play a recording back.
+func ConvertToAsciicast(r io.Reader, w io.Writer) error {
+	header, err := readRecordingHeader(r)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(asciicastHeader{
+		Version:   2,
+		Width:     int(header.Width),
+		Height:    int(header.Height),
+		Timestamp: header.Timestamp.Unix(),
+	}); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(r)
+	for {
+		frame, err := readRecordedFrame(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if frame.direction != DirectionServerToClient {
+			continue
+		}
+
+		seconds := float64(frame.deltaMicros) / 1e6
+		if err := enc.Encode([]interface{}{seconds, "o", string(frame.payload)}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	k8sv1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Kubevirt stream recording", func() {
+
+	var upgrader websocket.Upgrader
+	var server *ghttp.Server
+	var client KubevirtClient
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		client, err = GetKubevirtClientFromFlags(server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should record an echo session and replay the server bytes back", func() {
+		vncPath := "/apis/subresources.kubevirt.io/v1alpha1/namespaces/default/virtualmachines/testvm/vnc"
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", vncPath),
+			func(w http.ResponseWriter, r *http.Request) {
+				c, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					panic("server upgrader failed")
+				}
+				defer c.Close()
+
+				for {
+					mt, message, err := c.ReadMessage()
+					if err != nil {
+						break
+					}
+					if err := c.WriteMessage(mt, message); err != nil {
+						break
+					}
+				}
+			},
+		))
+
+		vnc, err := client.VM(k8sv1.NamespaceDefault).VNC("testvm")
+		Expect(err).ToNot(HaveOccurred())
+
+		var recording bytes.Buffer
+		recorder, err := NewRecorder(&recording, RecordingHeader{
+			StreamKind: StreamKindVNC,
+			Timestamp:  time.Now(),
+			Width:      800,
+			Height:     600,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		pipeInReader, pipeInWriter := io.Pipe()
+		pipeOutReader, pipeOutWriter := io.Pipe()
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			vnc.Stream(StreamOptions{
+				In:       pipeInReader,
+				Out:      pipeOutWriter,
+				Recorder: recorder,
+			})
+		}()
+
+		msg := []byte("hello, recorder!")
+		_, err = pipeInWriter.Write(msg)
+		Expect(err).ToNot(HaveOccurred())
+
+		bufOut := make([]byte, len(msg))
+		_, err = io.ReadFull(pipeOutReader, bufOut)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(bufOut).To(Equal(msg))
+
+		pipeInWriter.Close()
+		<-done
+
+		By("replaying the recording")
+		var replayed bytes.Buffer
+		err = Replay(bytes.NewReader(recording.Bytes()), &replayed, ReplayOptions{Speed: 1000})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(replayed.Bytes()).To(Equal(msg))
+	})
+
+	It("should convert a serial console recording to asciicast v2", func() {
+		var recording bytes.Buffer
+		recorder, err := NewRecorder(&recording, RecordingHeader{
+			StreamKind: StreamKindSerialConsole,
+			Timestamp:  time.Now(),
+			Width:      80,
+			Height:     24,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(recorder.Record(DirectionServerToClient, []byte("hello\n"))).To(Succeed())
+
+		var asciicast bytes.Buffer
+		Expect(ConvertToAsciicast(bytes.NewReader(recording.Bytes()), &asciicast)).To(Succeed())
+
+		Expect(asciicast.String()).To(ContainSubstring(`"version":2`))
+		Expect(asciicast.String()).To(ContainSubstring(fmt.Sprintf("%q", "hello\n")))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+})
@@ -0,0 +1,101 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"io"
+
+	"github.com/gorilla/websocket"
+)
+
+// StreamOptions wires the two ends of a StreamInterface to the process that
+// wants to talk to it, mirroring the shape of remotecommand.StreamOptions.
+type StreamOptions struct {
+	In  io.Reader
+	Out io.Writer
+	// Recorder, if set, receives a copy of every frame exchanged in both
+	// directions, tagged and timestamped for later Replay or conversion to
+	// asciicast.
+	Recorder *Recorder
+}
+
+// StreamInterface is implemented by every subresource that proxies a raw
+// byte stream over a websocket, such as VNC or the serial console.
+type StreamInterface interface {
+	// Stream copies bytes between the underlying websocket and the In/Out
+	// readers/writers in options until either side closes or errors.
+	Stream(options StreamOptions) error
+}
+
+// wsStreamer adapts a gorilla websocket connection, which is message
+// oriented, to the plain io.Reader/io.Writer world StreamOptions lives in.
+type wsStreamer struct {
+	conn *websocket.Conn
+}
+
+func (ws *wsStreamer) Stream(options StreamOptions) error {
+	errCh := make(chan error, 2)
+
+	go func() {
+		errCh <- ws.readFromStream(options.Out, options.Recorder)
+	}()
+	go func() {
+		errCh <- ws.writeToStream(options.In, options.Recorder)
+	}()
+
+	defer ws.conn.Close()
+	return <-errCh
+}
+
+func (ws *wsStreamer) readFromStream(out io.Writer, recorder *Recorder) error {
+	for {
+		_, message, err := ws.conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if recorder != nil {
+			recorder.Record(DirectionServerToClient, message)
+		}
+		if out == nil {
+			continue
+		}
+		if _, err := out.Write(message); err != nil {
+			return err
+		}
+	}
+}
+
+func (ws *wsStreamer) writeToStream(in io.Reader, recorder *Recorder) error {
+	buf := make([]byte, 4096)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if recorder != nil {
+				recorder.Record(DirectionClientToServer, buf[:n])
+			}
+			if writeErr := ws.conn.WriteMessage(websocket.BinaryMessage, buf[:n]); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,97 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/rest"
+)
+
+const subresourceGroupVersion = "subresources.kubevirt.io/v1alpha1"
+
+// subresourceURL builds the URL of a namespaced subresource endpoint served
+// by virt-api, e.g. .../virtualmachines/{name}/vnc.
+func subresourceURL(config *rest.Config, namespace, resource, name, subresource string) (*url.URL, error) {
+	u, err := url.Parse(config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+
+	u.Path = fmt.Sprintf("/apis/%s/namespaces/%s/%s/%s/%s",
+		subresourceGroupVersion, namespace, resource, name, subresource)
+
+	return u, nil
+}
+
+// dialSubresource upgrades a connection to the given namespaced subresource
+// endpoint and hands back the raw websocket connection. It applies config's
+// TLS settings and, if set, its BearerToken; other credentials in config,
+// such as basic auth or auth providers, are not sent.
+func dialSubresource(config *rest.Config, namespace, resource, name, subresource string) (*websocket.Conn, error) {
+	u, err := subresourceURL(config, namespace, resource, name, subresource)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &websocket.Dialer{TLSClientConfig: tlsConfig}
+
+	header := http.Header{}
+	if config.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+strings.TrimSpace(config.BearerToken))
+	}
+
+	conn, resp, err := dialer.Dial(u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("can't connect to %s: %d - %s", u.String(), resp.StatusCode, resp.Status)
+		}
+		return nil, fmt.Errorf("can't connect to %s: %v", u.String(), err)
+	}
+
+	return conn, nil
+}
+
+// asyncSubresourceHelper dials the given subresource and wraps the resulting
+// connection in a StreamInterface, ready for callers to pump stdin/stdout
+// through via Stream().
+func asyncSubresourceHelper(config *rest.Config, namespace, resource, name, subresource string) (StreamInterface, error) {
+	conn, err := dialSubresource(config, namespace, resource, name, subresource)
+	if err != nil {
+		return nil, err
+	}
+	return &wsStreamer{conn: conn}, nil
+}
@@ -0,0 +1,121 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+
+	"kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+// VMInterface groups everything a caller can do against the VirtualMachine
+// resource and its subresources, for a single namespace.
+type VMInterface interface {
+	Get(name string, options metav1.GetOptions) (*v1.VirtualMachine, error)
+	List(options metav1.ListOptions) (*v1.VirtualMachineList, error)
+	Create(vm *v1.VirtualMachine) (*v1.VirtualMachine, error)
+	Update(vm *v1.VirtualMachine) (*v1.VirtualMachine, error)
+	Delete(name string, options *metav1.DeleteOptions) error
+	Watch(options metav1.ListOptions) (watch.Interface, error)
+	VNC(name string) (StreamInterface, error)
+	SerialConsole(name string) (StreamInterface, error)
+	PortForward(name string, ports ...string) (PortForwarder, error)
+}
+
+type vm struct {
+	restClient *rest.RESTClient
+	config     *rest.Config
+	namespace  string
+	resource   string
+}
+
+func (v *vm) Get(name string, options metav1.GetOptions) (vm *v1.VirtualMachine, err error) {
+	vm = &v1.VirtualMachine{}
+	err = v.restClient.Get().
+		Namespace(v.namespace).
+		Resource(v.resource).
+		Name(name).
+		VersionedParams(&options, ParameterCodec).
+		Do().
+		Into(vm)
+	return
+}
+
+func (v *vm) List(options metav1.ListOptions) (vmList *v1.VirtualMachineList, err error) {
+	vmList = &v1.VirtualMachineList{}
+	err = v.restClient.Get().
+		Namespace(v.namespace).
+		Resource(v.resource).
+		VersionedParams(&options, ParameterCodec).
+		Do().
+		Into(vmList)
+	return
+}
+
+func (v *vm) Create(vmObj *v1.VirtualMachine) (result *v1.VirtualMachine, err error) {
+	result = &v1.VirtualMachine{}
+	err = v.restClient.Post().
+		Namespace(v.namespace).
+		Resource(v.resource).
+		Body(vmObj).
+		Do().
+		Into(result)
+	return
+}
+
+func (v *vm) Update(vmObj *v1.VirtualMachine) (result *v1.VirtualMachine, err error) {
+	result = &v1.VirtualMachine{}
+	err = v.restClient.Put().
+		Namespace(v.namespace).
+		Resource(v.resource).
+		Name(vmObj.ObjectMeta.Name).
+		Body(vmObj).
+		Do().
+		Into(result)
+	return
+}
+
+func (v *vm) Delete(name string, options *metav1.DeleteOptions) error {
+	return v.restClient.Delete().
+		Namespace(v.namespace).
+		Resource(v.resource).
+		Name(name).
+		Body(options).
+		Do().
+		Error()
+}
+
+func (v *vm) VNC(name string) (StreamInterface, error) {
+	return asyncSubresourceHelper(v.config, v.namespace, v.resource, name, "vnc")
+}
+
+// SerialConsole opens the same kind of byte stream as VNC, but against the
+// guest's serial port instead of its graphical framebuffer.
+func (v *vm) SerialConsole(name string) (StreamInterface, error) {
+	return asyncSubresourceHelper(v.config, v.namespace, v.resource, name, "console")
+}
+
+// NewVMList wraps the given VirtualMachines in a VirtualMachineList, mostly
+// useful for constructing fixtures in tests.
+func NewVMList(vms ...v1.VirtualMachine) *v1.VirtualMachineList {
+	return &v1.VirtualMachineList{Items: vms}
+}
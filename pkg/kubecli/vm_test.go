@@ -63,7 +63,11 @@ var _ = Describe("Kubevirt VM Client", func() {
 
 		Expect(server.ReceivedRequests()).To(HaveLen(1))
 		Expect(err).ToNot(HaveOccurred())
-		Expect(fetchedVM).To(Equal(vm))
+		// The REST client decodes through WithoutConversionCodecFactory, which
+		// clears TypeMeta on decode by convention - a typed Get never sees it.
+		expectedVM := vm.DeepCopy()
+		expectedVM.TypeMeta = k8smetav1.TypeMeta{}
+		Expect(fetchedVM).To(Equal(expectedVM))
 	})
 
 	It("should detect non existent VMs", func() {
@@ -102,7 +106,10 @@ var _ = Describe("Kubevirt VM Client", func() {
 
 		Expect(server.ReceivedRequests()).To(HaveLen(1))
 		Expect(err).ToNot(HaveOccurred())
-		Expect(createdVM).To(Equal(vm))
+		// See the Get case above: TypeMeta is cleared by the codec on decode.
+		expectedVM := vm.DeepCopy()
+		expectedVM.TypeMeta = k8smetav1.TypeMeta{}
+		Expect(createdVM).To(Equal(expectedVM))
 	})
 
 	It("should update a VM", func() {
@@ -115,7 +122,10 @@ var _ = Describe("Kubevirt VM Client", func() {
 
 		Expect(server.ReceivedRequests()).To(HaveLen(1))
 		Expect(err).ToNot(HaveOccurred())
-		Expect(updatedVM).To(Equal(vm))
+		// See the Get case above: TypeMeta is cleared by the codec on decode.
+		expectedVM := vm.DeepCopy()
+		expectedVM.TypeMeta = k8smetav1.TypeMeta{}
+		Expect(updatedVM).To(Equal(expectedVM))
 	})
 
 	It("should delete a VM", func() {
@@ -220,6 +230,97 @@ var _ = Describe("Kubevirt VM Client", func() {
 		Expect(bufOut).To(Equal(bufIn))
 	})
 
+	It("should allow to connect a stream to the serial console of a VM", func() {
+		consolePath := "/apis/subresources.kubevirt.io/v1alpha1/namespaces/default/virtualmachines/testvm/console"
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", consolePath),
+			func(w http.ResponseWriter, r *http.Request) {
+				_, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					return
+				}
+			},
+		))
+		_, err := client.VM(k8sv1.NamespaceDefault).SerialConsole("testvm")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should handle a failure connecting to the serial console of a VM", func() {
+		consolePath := "/apis/subresources.kubevirt.io/v1alpha1/namespaces/default/virtualmachines/testvm/console"
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", consolePath),
+			func(w http.ResponseWriter, r *http.Request) {
+				return
+			},
+		))
+		_, err := client.VM(k8sv1.NamespaceDefault).SerialConsole("testvm")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should exchange data with the serial console of a VM", func() {
+		consolePath := "/apis/subresources.kubevirt.io/v1alpha1/namespaces/default/virtualmachines/testvm/console"
+
+		server.AppendHandlers(ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", consolePath),
+			func(w http.ResponseWriter, r *http.Request) {
+				c, err := upgrader.Upgrade(w, r, nil)
+				if err != nil {
+					panic("server upgrader failed")
+				}
+				defer c.Close()
+
+				for {
+					mt, message, err := c.ReadMessage()
+					if err != nil {
+						io.WriteString(GinkgoWriter, fmt.Sprintf("server read failed: %v\n", err))
+						break
+					}
+
+					err = c.WriteMessage(mt, message)
+					if err != nil {
+						io.WriteString(GinkgoWriter, fmt.Sprintf("server write failed: %v\n", err))
+						break
+					}
+				}
+			},
+		))
+
+		By("establishing connection")
+
+		console, err := client.VM(k8sv1.NamespaceDefault).SerialConsole("testvm")
+		Expect(err).ToNot(HaveOccurred())
+
+		By("wiring the pipes")
+
+		pipeInReader, pipeInWriter := io.Pipe()
+		pipeOutReader, pipeOutWriter := io.Pipe()
+
+		go func() {
+			console.Stream(StreamOptions{
+				In:  pipeInReader,
+				Out: pipeOutWriter,
+			})
+		}()
+
+		By("sending data around")
+		msg := "hello, console!"
+		bufIn := make([]byte, 64)
+		copy(bufIn[:], msg)
+
+		_, err = pipeInWriter.Write(bufIn)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("reading back data")
+		bufOut := make([]byte, 64)
+		_, err = pipeOutReader.Read(bufOut)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("checking the result")
+		Expect(bufOut).To(Equal(bufIn))
+	})
+
 	AfterEach(func() {
 		server.Close()
 	})
@@ -0,0 +1,283 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+
+	"kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+const watchAcceptHeader = "application/json;stream=watch"
+
+// watchReconnectBackoffMin and watchReconnectBackoffMax bound the delay
+// waitBeforeReconnect waits before re-issuing a dropped watch request. The
+// delay doubles on each consecutive failure (reset on a successfully
+// decoded event), so a server that closes the stream promptly still gets
+// backed off further the longer the outage lasts, instead of being
+// hammered at a constant rate indefinitely.
+const (
+	watchReconnectBackoffMin = 200 * time.Millisecond
+	watchReconnectBackoffMax = 10 * time.Second
+)
+
+// Watch starts streaming change events for VirtualMachines in the client's
+// namespace. Unlike a plain client-go Watch, the returned watch.Interface
+// transparently reconnects on a dropped connection or a 410 Gone (resuming
+// from the last seen resourceVersion, or from scratch if the server reports
+// the history was compacted) and swallows Bookmark events after using them
+// to advance the resume point.
+func (v *vm) Watch(options metav1.ListOptions) (watch.Interface, error) {
+	// restClient.Client is only non-nil when the rest.Config needed a custom
+	// Transport (e.g. TLS); a plain http(s) client falls back to
+	// http.DefaultClient, mirroring what rest.Request itself does internally.
+	httpClient := v.restClient.Client
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	w := &vmWatcher{
+		httpClient:      httpClient,
+		config:          v.config,
+		namespace:       v.namespace,
+		resource:        v.resource,
+		resourceVersion: options.ResourceVersion,
+		resultChan:      make(chan watch.Event),
+		stopCh:          make(chan struct{}),
+	}
+
+	stream, err := w.open()
+	if err != nil {
+		return nil, err
+	}
+
+	go w.run(stream)
+	return w, nil
+}
+
+// vmWatcher implements watch.Interface on top of the raw kubevirt.io watch
+// endpoint, handling reconnection itself instead of relying on a caller-side
+// reflector. It reuses the VM client's own *http.Client, which already
+// carries whatever TLS and auth settings rest.RESTClientFor set up, instead
+// of assembling a second one from the rest.Config.
+type vmWatcher struct {
+	httpClient *http.Client
+	config     *rest.Config
+	namespace  string
+	resource   string
+
+	mu              sync.Mutex
+	resourceVersion string
+	currentStream   io.ReadCloser
+
+	// reconnectBackoff is only ever touched from the run goroutine.
+	reconnectBackoff time.Duration
+
+	resultChan chan watch.Event
+	stopCh     chan struct{}
+	stopOnce   sync.Once
+}
+
+func (w *vmWatcher) watchURL() (string, error) {
+	u, err := url.Parse(w.config.Host)
+	if err != nil {
+		return "", err
+	}
+	u.Path = fmt.Sprintf("/apis/%s/%s/namespaces/%s/%s", v1.GroupName, v1.GroupVersion, w.namespace, w.resource)
+
+	q := u.Query()
+	q.Set("watch", "true")
+	w.mu.Lock()
+	rv := w.resourceVersion
+	w.mu.Unlock()
+	if rv != "" {
+		q.Set("resourceVersion", rv)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// open issues the watch request and returns the response body, retrying
+// once from the latest resource version if the server reports the
+// requested one is gone.
+func (w *vmWatcher) open() (io.ReadCloser, error) {
+	target, err := w.watchURL()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", watchAcceptHeader)
+
+	// w.httpClient's Transport (built by rest.RESTClientFor) already
+	// attaches whatever auth the rest.Config calls for.
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusGone {
+		w.mu.Lock()
+		retry := w.resourceVersion != ""
+		w.resourceVersion = ""
+		w.mu.Unlock()
+		if retry {
+			resp.Body.Close()
+			return w.open()
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected watch response: %d - %s", resp.StatusCode, string(body))
+	}
+
+	w.mu.Lock()
+	w.currentStream = resp.Body
+	w.mu.Unlock()
+
+	return resp.Body, nil
+}
+
+func (w *vmWatcher) run(stream io.ReadCloser) {
+	defer close(w.resultChan)
+	decoder := json.NewDecoder(stream)
+
+	for {
+		var event metav1.WatchEvent
+		if err := decoder.Decode(&event); err != nil {
+			stream.Close()
+
+			if !w.waitBeforeReconnect() {
+				return
+			}
+
+			next, err := w.open()
+			if err != nil {
+				return
+			}
+			stream = next
+			decoder = json.NewDecoder(stream)
+			continue
+		}
+
+		w.reconnectBackoff = 0
+
+		eventType := watch.EventType(event.Type)
+
+		if eventType == watch.Error {
+			status := &metav1.Status{}
+			if err := json.Unmarshal(event.Object.Raw, status); err != nil {
+				continue
+			}
+
+			// Unlike an HTTP 410 on (re)connect, a compaction signalled
+			// in-band via an ERROR frame doesn't close the connection, so
+			// it has to be caught here too.
+			if status.Reason == metav1.StatusReasonExpired || status.Reason == metav1.StatusReasonGone {
+				w.mu.Lock()
+				w.resourceVersion = ""
+				w.mu.Unlock()
+			}
+
+			select {
+			case w.resultChan <- watch.Event{Type: watch.Error, Object: status}:
+			case <-w.stopCh:
+				stream.Close()
+				return
+			}
+			continue
+		}
+
+		vmObj := &v1.VirtualMachine{}
+		if err := json.Unmarshal(event.Object.Raw, vmObj); err != nil {
+			continue
+		}
+
+		w.mu.Lock()
+		w.resourceVersion = vmObj.ObjectMeta.ResourceVersion
+		w.mu.Unlock()
+
+		if eventType == watch.Bookmark {
+			// Bookmarks only exist to advance resourceVersion, already
+			// done above; never surface them to consumers.
+			continue
+		}
+
+		select {
+		case w.resultChan <- watch.Event{Type: eventType, Object: vmObj}:
+		case <-w.stopCh:
+			stream.Close()
+			return
+		}
+	}
+}
+
+// waitBeforeReconnect backs off before the caller reconnects, doubling the
+// delay (up to watchReconnectBackoffMax) on each call until run sees a
+// successfully decoded event again. It returns false (without having
+// reconnected) if Stop is called in the meantime, so a dropped connection
+// after Stop never issues another request.
+func (w *vmWatcher) waitBeforeReconnect() bool {
+	if w.reconnectBackoff < watchReconnectBackoffMin {
+		w.reconnectBackoff = watchReconnectBackoffMin
+	}
+
+	select {
+	case <-w.stopCh:
+		return false
+	case <-time.After(w.reconnectBackoff):
+	}
+
+	if w.reconnectBackoff *= 2; w.reconnectBackoff > watchReconnectBackoffMax {
+		w.reconnectBackoff = watchReconnectBackoffMax
+	}
+	return true
+}
+
+func (w *vmWatcher) ResultChan() <-chan watch.Event {
+	return w.resultChan
+}
+
+func (w *vmWatcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		w.mu.Lock()
+		if w.currentStream != nil {
+			w.currentStream.Close()
+		}
+		w.mu.Unlock()
+	})
+}
@@ -0,0 +1,170 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2017 Red Hat, Inc.
+ *
+ */
+
+package kubecli
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"kubevirt.io/kubevirt/pkg/api/v1"
+)
+
+func writeWatchEvent(w io.Writer, eventType string, vm *v1.VirtualMachine, resourceVersion string) {
+	vm.ObjectMeta.ResourceVersion = resourceVersion
+	raw, err := json.Marshal(vm)
+	Expect(err).ToNot(HaveOccurred())
+
+	writeRawWatchEvent(w, eventType, raw)
+}
+
+func writeStatusWatchEvent(w io.Writer, status *metav1.Status) {
+	raw, err := json.Marshal(status)
+	Expect(err).ToNot(HaveOccurred())
+
+	writeRawWatchEvent(w, "ERROR", raw)
+}
+
+func writeRawWatchEvent(w io.Writer, eventType string, raw []byte) {
+	event := metav1.WatchEvent{Type: eventType, Object: runtime.RawExtension{Raw: raw}}
+	data, err := json.Marshal(event)
+	Expect(err).ToNot(HaveOccurred())
+
+	_, err = w.Write(data)
+	Expect(err).ToNot(HaveOccurred())
+}
+
+var _ = Describe("Kubevirt VM Watch", func() {
+
+	var server *ghttp.Server
+	var client KubevirtClient
+	basePath := "/apis/kubevirt.io/v1alpha1/namespaces/default/virtualmachines"
+
+	BeforeEach(func() {
+		var err error
+		server = ghttp.NewServer()
+		client, err = GetKubevirtClientFromFlags(server.URL(), "")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should stream decoded events, skip bookmarks, and reconnect from the last resourceVersion", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", basePath, "watch=true"),
+				func(w http.ResponseWriter, r *http.Request) {
+					flusher := w.(http.Flusher)
+					writeWatchEvent(w, "ADDED", v1.NewMinimalVM("vm1"), "1")
+					flusher.Flush()
+					writeWatchEvent(w, "MODIFIED", v1.NewMinimalVM("vm1"), "2")
+					flusher.Flush()
+					writeWatchEvent(w, "BOOKMARK", v1.NewMinimalVM("vm1"), "3")
+					flusher.Flush()
+				},
+			),
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", basePath, "resourceVersion=3&watch=true"),
+				func(w http.ResponseWriter, r *http.Request) {
+					writeWatchEvent(w, "ADDED", v1.NewMinimalVM("vm2"), "4")
+					w.(http.Flusher).Flush()
+				},
+			),
+		)
+
+		watcher, err := client.VM(k8sv1.NamespaceDefault).Watch(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		defer watcher.Stop()
+
+		event := <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Added))
+		Expect(event.Object.(*v1.VirtualMachine).Name).To(Equal("vm1"))
+		Expect(event.Object.(*v1.VirtualMachine).ResourceVersion).To(Equal("1"))
+
+		event = <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Modified))
+		Expect(event.Object.(*v1.VirtualMachine).ResourceVersion).To(Equal("2"))
+
+		// The BOOKMARK event is never delivered, but the reconnect after the
+		// server closes the first stream resumes from its resourceVersion.
+		event = <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Added))
+		Expect(event.Object.(*v1.VirtualMachine).Name).To(Equal("vm2"))
+
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	It("should surface an ERROR frame as a watch.Error event and restart from scratch on compaction", func() {
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", basePath, "watch=true"),
+				func(w http.ResponseWriter, r *http.Request) {
+					flusher := w.(http.Flusher)
+					writeWatchEvent(w, "ADDED", v1.NewMinimalVM("vm1"), "1")
+					flusher.Flush()
+					writeStatusWatchEvent(w, &metav1.Status{
+						Status:  metav1.StatusFailure,
+						Reason:  metav1.StatusReasonExpired,
+						Message: "too old resource version",
+					})
+					flusher.Flush()
+				},
+			),
+			ghttp.CombineHandlers(
+				// The ERROR frame signalled compaction in-band, so the
+				// reconnect must drop resourceVersion and restart from the
+				// latest list instead of resuming from "1".
+				ghttp.VerifyRequest("GET", basePath, "watch=true"),
+				func(w http.ResponseWriter, r *http.Request) {
+					writeWatchEvent(w, "ADDED", v1.NewMinimalVM("vm2"), "5")
+					w.(http.Flusher).Flush()
+				},
+			),
+		)
+
+		watcher, err := client.VM(k8sv1.NamespaceDefault).Watch(metav1.ListOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		defer watcher.Stop()
+
+		event := <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Added))
+		Expect(event.Object.(*v1.VirtualMachine).Name).To(Equal("vm1"))
+
+		event = <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Error))
+		Expect(event.Object.(*metav1.Status).Reason).To(Equal(metav1.StatusReasonExpired))
+
+		event = <-watcher.ResultChan()
+		Expect(event.Type).To(Equal(watch.Added))
+		Expect(event.Object.(*v1.VirtualMachine).Name).To(Equal("vm2"))
+
+		Expect(server.ReceivedRequests()).To(HaveLen(2))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+})